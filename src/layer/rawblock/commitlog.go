@@ -1,11 +1,18 @@
 package rawblock
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"hash/fnv"
 	"log"
 	"math"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
@@ -17,11 +24,48 @@ const (
 	defaultBatchSize       = 4096
 	defaultMaxPendingBytes = 10000000
 	defaultFlushEvery      = time.Millisecond
+	defaultNumShards       = 1
+	// defaultPendingQueueSize is the buffer depth of a shard's ingest channel.
+	// It only needs to be deep enough to avoid unnecessary blocking on Write()
+	// under normal load; actual backpressure is governed by MaxPendingBytes.
+	defaultPendingQueueSize = 1024
+	// defaultSyncQueueSize bounds how many in-flight (committing, not yet
+	// acknowledged) batches a shard may have outstanding at once.
+	defaultSyncQueueSize = 64
 
-	commitLogKey            = "commitlog-"
-	commitLogKeyTupleLength = 2
+	// fdbMaxTxnBytes keeps batches safely under FDB's ~10MB transaction size
+	// limit, leaving headroom for key overhead and the record headers.
+	fdbMaxTxnBytes = 9000000
+
+	// tailChannelBufferSize bounds how far a Tail() consumer can lag behind
+	// before the per-shard goroutine feeding it blocks.
+	tailChannelBufferSize = 256
+	// tailMinPollInterval/tailMaxPollInterval bound the backoff Tail() uses
+	// once it has caught up to a shard's tail and is waiting for new writes.
+	tailMinPollInterval = time.Millisecond
+	tailMaxPollInterval = 250 * time.Millisecond
+
+	commitLogKey = "commitlog-"
+	// commitLogKeyTupleLength is commitLogKey, writerID, shardIdx, seq.
+	commitLogKeyTupleLength = 4
+
+	// recordMagic identifies a well-formed commitlog record value so that a
+	// replayer can immediately recognize a key that isn't one (or is from an
+	// incompatible future format) instead of misinterpreting its bytes.
+	recordMagic = "TCL1"
+	// recordHeaderLen is len(recordMagic) + crc32c(4 bytes) + seq(8 bytes) +
+	// timestamp(8 bytes).
+	recordHeaderLen = 4 + 4 + 8 + 8
 )
 
+// crc32cTable is the Castagnoli polynomial, same as Pebble/RocksDB/etc. use
+// for their record checksums.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorruptRecord is returned by decodeRecord when a record's payload does
+// not match its checksum.
+var errCorruptRecord = errors.New("commitlog: record failed checksum verification")
+
 type clStatus int
 
 const (
@@ -30,37 +74,214 @@ const (
 	clStatusClosed
 )
 
-// truncationToken is a token that can be passed to the commitlog to truncate the commitlogs up to
-// a specific point. It should be treated as opaque by external callers.
-type truncationToken struct {
-	upTo tuple.Tuple
+// TruncationToken is a token that can be passed to the commitlog to truncate
+// the commitlogs up to a specific point. Its fields are unexported and it
+// should otherwise be treated as opaque by external callers; use Compare (or
+// Before) to order it against another token, e.g. from within a
+// SupersededFunc.
+type TruncationToken struct {
+	// upTo holds one tuple per shard, indexed by shard index, marking the exclusive
+	// upper bound to clear up to for that shard. An entry is nil if the corresponding
+	// shard had no data to flush at the time the token was generated.
+	upTo []tuple.Tuple
+}
+
+// Compare returns a negative number if t sorts before other, zero if they
+// represent the same position, and a positive number if t sorts after other.
+// Corresponding shard positions are compared by their packed FDB key bytes; a
+// shard with no recorded position sorts before any shard that has one. This
+// lets a caller-supplied SupersededFunc compare a record's Token against a
+// watermark the caller tracks externally (e.g. "already flushed into the
+// primary tables"), without needing to see into this package at all.
+func (t TruncationToken) Compare(other TruncationToken) int {
+	n := len(t.upTo)
+	if len(other.upTo) > n {
+		n = len(other.upTo)
+	}
+	for i := 0; i < n; i++ {
+		var a, b tuple.Tuple
+		if i < len(t.upTo) {
+			a = t.upTo[i]
+		}
+		if i < len(other.upTo) {
+			b = other.upTo[i]
+		}
+		if a == nil && b == nil {
+			continue
+		}
+		if a == nil {
+			return -1
+		}
+		if b == nil {
+			return 1
+		}
+		if c := bytes.Compare(a.Pack(), b.Pack()); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// Before reports whether t sorts strictly before other. It's a convenience
+// wrapper around Compare for the common case of checking a record's Token
+// against a single high-water mark.
+func (t TruncationToken) Before(other TruncationToken) bool {
+	return t.Compare(other) < 0
 }
 
 // Commitlog is the interface for an FDB-backed commitlog.
 type Commitlog interface {
-	Write([]byte) error
+	Write(b []byte) error
+	WriteRouted(routingKey, b []byte) error
+	WriteAsync(b []byte) (*WriteHandle, error)
+	WriteAsyncRouted(routingKey, b []byte) (*WriteHandle, error)
 	Open() error
 	Close() error
-	WaitForRotation() (truncationToken, error)
-	Truncate(token truncationToken) error
+	WaitForRotation() (TruncationToken, error)
+	Truncate(token TruncationToken) error
+	ActiveLogs() ([]LogInfo, error)
+	Read(fromToken, toToken TruncationToken) (Iterator, error)
+	RunValueLogGC(discardRatio float64) error
+	Tail(ctx context.Context, fromToken TruncationToken) (<-chan Record, error)
+}
+
+// LogInfo describes the state of a single shard's key range as observed by
+// ActiveLogs(). A bootstrap/replay manager uses this to decide whether a
+// shard still needs to be watched for incoming writes (Sealed == false) or
+// whether everything accepted by it so far has already been committed to FDB
+// and it can be treated as a closed range (Sealed == true).
+type LogInfo struct {
+	WriterID string
+	ShardIdx int
+	Sealed   bool
+}
+
+// Record is a single entry read back from the commitlog via Read().
+type Record struct {
+	// Token identifies this record's exact position and can be used as the
+	// fromToken of a subsequent Read() call to resume after it.
+	Token     TruncationToken
+	Seq       int64
+	Timestamp time.Time
+	Payload   []byte
+}
+
+// Iterator reads back records previously written via Write()/WriteAsync().
+// It is not safe for concurrent use.
+type Iterator interface {
+	// Next advances the iterator and reports whether a record is available.
+	// It stops cleanly (returning false with a nil Err()) once it reaches the
+	// end of the requested range, or once it hits a record at the tail of a
+	// shard's range that fails its checksum, since that's the signature of a
+	// write that hasn't committed yet rather than real corruption.
+	Next() bool
+	// Record returns the record the iterator currently points to. It is only
+	// valid after a call to Next() that returned true.
+	Record() Record
+	// Err returns the first hard error encountered, if any. Should be checked
+	// after Next() returns false.
+	Err() error
+	// Close releases the underlying FDB range iterator/futures.
+	Close()
+}
+
+// CommitlogMetrics lets callers observe the internal write/batch pipeline
+// (e.g. by wiring these calls to Prometheus instruments named
+// commitlog_write_latency_seconds, commitlog_batch_bytes,
+// commitlog_queue_depth, and commitlog_sync_queue_depth) without this package
+// taking on a metrics library dependency directly.
+type CommitlogMetrics interface {
+	// ObserveWriteLatency is called once per write, from enqueue to the
+	// batch containing it being durably committed (or failing).
+	ObserveWriteLatency(d time.Duration)
+	// ObserveBatchBytes is called once per FDB transaction committed, with
+	// the total encoded size (including record headers) of that transaction.
+	ObserveBatchBytes(n int)
+	// SetQueueDepth reports how many writes are sitting in shardIdx's ingest
+	// queue, waiting to be folded into a batch.
+	SetQueueDepth(shardIdx int, n int)
+	// SetSyncQueueDepth reports how many of shardIdx's batches have been
+	// committed to FDB but not yet acknowledged back to their callers.
+	SetSyncQueueDepth(shardIdx int, n int)
 }
 
+type noopCommitlogMetrics struct{}
+
+func (noopCommitlogMetrics) ObserveWriteLatency(time.Duration) {}
+func (noopCommitlogMetrics) ObserveBatchBytes(int)             {}
+func (noopCommitlogMetrics) SetQueueDepth(int, int)            {}
+func (noopCommitlogMetrics) SetSyncQueueDepth(int, int)        {}
+
 // CommitlogOptions encapsulates the options for the commit log.
 type CommitlogOptions struct {
 	IdealBatchSize  int
 	MaxPendingBytes int
-	FlushEvery      time.Duration
+	// FlushEvery acts as an idle timeout for batch coalescing: once a batch
+	// has its first write, the batcher keeps folding newly arrived writes
+	// into it as long as they keep arriving within FlushEvery of each other
+	// (or until IdealBatchSize/the FDB transaction size limit is hit). Set to
+	// 0 to cut a batch as soon as the ingest queue is momentarily empty,
+	// which minimizes latency at the cost of smaller batches under light load.
+	FlushEvery time.Duration
+	// WriterID identifies this process in the commitlog key space so that
+	// multiple writers (e.g. one per host) can append to the same commitlog
+	// without colliding on each other's keys.
+	WriterID string
+	// NumShards is the number of independent (writerID, shard) key ranges this
+	// writer appends to. Each shard has its own batching pipeline and its own
+	// tail key, so increasing NumShards trades a single point of FDB
+	// contention on the tail key for linear write throughput.
+	NumShards int
+	// Metrics receives pipeline observations. Defaults to a no-op if nil.
+	Metrics CommitlogMetrics
+	// IsSuperseded, if set, lets RunValueLogGC() reclaim FDB keyspace that the
+	// caller has confirmed is durably absorbed elsewhere (e.g. flushed into
+	// the primary tsdb tables) without needing an external cleanup manager to
+	// separately track which commitlog ranges are safe to drop.
+	IsSuperseded SupersededFunc
+	// MaxRetentionBytes, if > 0, is a floor on how many trailing bytes of each
+	// shard RunValueLogGC() will always leave alone, regardless of what
+	// IsSuperseded reports for them. Acts as a safety margin against a
+	// mistaken or lagging superseded signal.
+	MaxRetentionBytes int64
+	// MaxRetentionAge is the age-based counterpart of MaxRetentionBytes:
+	// RunValueLogGC() never reclaims a record younger than this.
+	MaxRetentionAge time.Duration
 }
 
+// SupersededFunc reports whether the record at token has already been
+// durably absorbed elsewhere and is therefore safe for RunValueLogGC() to
+// reclaim from the commitlog's FDB keyspace.
+type SupersededFunc func(token TruncationToken) bool
+
 // NewCommitlogOptions creates a new CommitlogOptions.
 func NewCommitlogOptions() CommitlogOptions {
 	return CommitlogOptions{
 		IdealBatchSize:  defaultBatchSize,
 		MaxPendingBytes: defaultMaxPendingBytes,
 		FlushEvery:      defaultFlushEvery,
+		WriterID:        defaultWriterID(),
+		NumShards:       defaultNumShards,
+		Metrics:         noopCommitlogMetrics{},
 	}
 }
 
+func defaultWriterID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown-host"
+	}
+	return host
+}
+
+// flushOutcome represents the "committed" phase of a write: it is resolved
+// once the FDB transaction that included it has actually committed (or
+// failed). The "enqueued" phase (the write has been accepted onto the
+// shard's pipeline and its ordering relative to other writes is fixed) is
+// implicit the moment enqueue() hands the fragment to the shard's ingest
+// channel, which is what lets WriteAsync() hand back a handle without
+// waiting for the batch to be cut or committed (it only blocks while that
+// channel is full).
 type flushOutcome struct {
 	// TODO(rartoul): Fix this, but last ID can be nil in the case
 	// that there was no data to flush. This is useful because it
@@ -87,29 +308,167 @@ func (f *flushOutcome) notify(lastID tuple.Tuple, err error) {
 	close(f.doneCh)
 }
 
+// encodeRecord wraps payload with the header a replayer needs to detect torn
+// or corrupted writes: magic bytes, a CRC32C of the payload, the record's
+// shard-local monotonic seq, and the wall-clock time it was flushed.
+func encodeRecord(seq int64, timestamp time.Time, payload []byte) []byte {
+	buf := make([]byte, recordHeaderLen+len(payload))
+	copy(buf[0:4], recordMagic)
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crc32cTable))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(seq))
+	binary.BigEndian.PutUint64(buf[16:24], uint64(timestamp.UnixNano()))
+	copy(buf[recordHeaderLen:], payload)
+	return buf
+}
+
+// decodeRecord unwraps a record previously produced by encodeRecord, verifying
+// its checksum. If the checksum doesn't match it still returns the seq/
+// timestamp/payload it was able to parse alongside errCorruptRecord, so that
+// callers (namely the Iterator's tail-torn-write check) can decide whether the
+// mismatch is expected or a hard error without re-parsing the header.
+func decodeRecord(raw []byte) (seq int64, timestamp time.Time, payload []byte, err error) {
+	if len(raw) < recordHeaderLen {
+		return 0, time.Time{}, nil, fmt.Errorf("commitlog: record too short to contain a header: %d bytes", len(raw))
+	}
+	if string(raw[0:4]) != recordMagic {
+		return 0, time.Time{}, nil, fmt.Errorf("commitlog: record has bad magic bytes: %x", raw[0:4])
+	}
+
+	crc := binary.BigEndian.Uint32(raw[4:8])
+	seq = int64(binary.BigEndian.Uint64(raw[8:16]))
+	timestamp = time.Unix(0, int64(binary.BigEndian.Uint64(raw[16:24])))
+	payload = raw[recordHeaderLen:]
+
+	if crc32.Checksum(payload, crc32cTable) != crc {
+		return seq, timestamp, payload, errCorruptRecord
+	}
+	return seq, timestamp, payload, nil
+}
+
+var writeHandlePool = sync.Pool{
+	New: func() interface{} { return &WriteHandle{} },
+}
+
+// WriteHandle is returned by Commitlog.WriteAsync() and lets the caller
+// decouple "my write has been accepted and its order within its shard is
+// fixed" (true as soon as WriteAsync() returns) from "my write is durably
+// committed" (true once SyncWait() returns nil). This mirrors Pebble's
+// ApplyNoSyncWait/SyncWait split. The handle does not carry the write's
+// sequence number, which the batcher assigns later, and the write is not
+// visible to Read() or Tail() until its batch has committed.
+type WriteHandle struct {
+	outcome *flushOutcome
+}
+
+// SyncWait blocks until the FDB transaction that absorbed this write has
+// committed and returns the resulting error. The handle must not be used
+// again after SyncWait returns.
+func (h *WriteHandle) SyncWait() error {
+	err := h.outcome.waitForFlush()
+	h.outcome = nil
+	writeHandlePool.Put(h)
+	return err
+}
+
 type commitlog struct {
 	sync.Mutex
-	status        clStatus
-	db            fdb.Database
-	prevBatch     []byte
-	currBatch     []byte
-	lastFlushTime time.Time
-	lastIdx       int64
-	flushOutcome  *flushOutcome
-	closeCh       chan struct{}
-	closeDoneCh   chan error
-	opts          CommitlogOptions
+	status clStatus
+	opts   CommitlogOptions
+	db     fdb.Database
+	shards []*commitlogShard
+	// inflightWG tracks enqueue() calls that have passed the open-status
+	// check but haven't yet finished handing their fragment to a shard's
+	// ingest channel, so Close() can wait for them before it starts draining
+	// shards, instead of racing them.
+	inflightWG sync.WaitGroup
+	rrCounter  uint64
+}
+
+func (c *commitlog) Write(b []byte) error {
+	return c.WriteRouted(nil, b)
+}
+
+// WriteRouted behaves like Write(), but hashes routingKey to pick which shard
+// the write lands in. Writes that share a routingKey land in the same shard
+// and therefore observe a relative order; writes with a nil routingKey are
+// spread round-robin across shards for maximum throughput. Callers that only
+// have one shard (the default) can ignore this and just call Write().
+func (c *commitlog) WriteRouted(routingKey, b []byte) error {
+	outcome, err := c.enqueue(routingKey, b)
+	if err != nil {
+		return err
+	}
+	return outcome.waitForFlush()
+}
+
+// WriteAsync enqueues b the same way Write() does, but returns immediately
+// with a *WriteHandle instead of blocking until the write is durable. Callers
+// that only need the write to be accepted and ordered can proceed right away
+// and call SyncWait() on the handle later, once they actually need the
+// durability guarantee.
+func (c *commitlog) WriteAsync(b []byte) (*WriteHandle, error) {
+	return c.WriteAsyncRouted(nil, b)
+}
+
+// WriteAsyncRouted is the WriteAsync() counterpart of WriteRouted().
+func (c *commitlog) WriteAsyncRouted(routingKey, b []byte) (*WriteHandle, error) {
+	outcome, err := c.enqueue(routingKey, b)
+	if err != nil {
+		return nil, err
+	}
+
+	handle := writeHandlePool.Get().(*WriteHandle)
+	handle.outcome = outcome
+	return handle, nil
+}
+
+// enqueue picks the shard routingKey maps to and hands b off to its ingest
+// pipeline, returning the flushOutcome that will be resolved once the batch
+// it ends up in is durably committed. It never waits for a batch to be cut
+// or committed; it only blocks while the shard's ingest channel is full.
+func (c *commitlog) enqueue(routingKey, b []byte) (*flushOutcome, error) {
+	if len(b) == 0 {
+		return nil, errors.New("commit log can not write empty chunk")
+	}
+
+	c.Lock()
+	if c.status != clStatusOpen {
+		c.Unlock()
+		return nil, errors.New("cannot write into commit log that is not open")
+	}
+	shard := c.shardFor(routingKey)
+	c.inflightWG.Add(1)
+	c.Unlock()
+	defer c.inflightWG.Done()
+
+	return shard.enqueue(b)
+}
+
+// shardFor must be called while holding c.Lock().
+func (c *commitlog) shardFor(routingKey []byte) *commitlogShard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	if routingKey == nil {
+		idx := atomic.AddUint64(&c.rrCounter, 1) % uint64(len(c.shards))
+		return c.shards[idx]
+	}
+
+	h := fnv.New32a()
+	h.Write(routingKey)
+	idx := uint64(h.Sum32()) % uint64(len(c.shards))
+	return c.shards[idx]
 }
 
 // NewCommitlog creates a new commitlog.
 func NewCommitlog(db fdb.Database, opts CommitlogOptions) Commitlog {
+	if opts.Metrics == nil {
+		opts.Metrics = noopCommitlogMetrics{}
+	}
 	return &commitlog{
-		status:       clStatusUnopened,
-		db:           db,
-		flushOutcome: newFlushOutcome(),
-		closeCh:      make(chan struct{}, 1),
-		closeDoneCh:  make(chan error, 1),
-		opts:         opts,
+		status: clStatusUnopened,
+		db:     db,
+		opts:   opts,
 	}
 }
 
@@ -120,36 +479,34 @@ func (c *commitlog) Open() error {
 		return errors.New("commitlog cannot be opened more than once")
 	}
 
-	// "Bootstrap" the latest existing index to maintain a monotonically increasing
-	// value for the commitlog chunk indices.
-	existingIdx, ok, err := c.getLatestExistingIndex()
-	if err != nil {
-		return err
+	numShards := c.opts.NumShards
+	if numShards < 1 {
+		numShards = 1
 	}
-	if !ok {
-		existingIdx = -1
-	}
-	c.lastIdx = existingIdx
-	fmt.Println(c.lastIdx)
 
-	c.status = clStatusOpen
+	shards := make([]*commitlogShard, numShards)
+	for i := 0; i < numShards; i++ {
+		shard := newCommitlogShard(c.db, c.opts, i)
 
-	go func() {
-		for {
-			i := 0
-			select {
-			case <-c.closeCh:
-				c.closeDoneCh <- c.flush()
-				return
-			default:
-			}
-			time.Sleep(time.Millisecond)
-			if err := c.flush(); err != nil {
-				log.Printf("error flushing commitlog: %v", err)
-			}
-			i++
+		// "Bootstrap" the latest existing index for this shard to maintain a
+		// monotonically increasing value for its commitlog chunk indices.
+		existingIdx, ok, err := shard.getLatestExistingIndex()
+		if err != nil {
+			return err
 		}
-	}()
+		if !ok {
+			existingIdx = -1
+		}
+		shard.lastIdx = existingIdx
+		shards[i] = shard
+	}
+	c.shards = shards
+
+	for _, shard := range c.shards {
+		shard.startPipeline()
+	}
+
+	c.status = clStatusOpen
 
 	return nil
 }
@@ -161,130 +518,535 @@ func (c *commitlog) Close() error {
 		return errors.New("cannot close commit log that is not open")
 	}
 	c.status = clStatusClosed
+	shards := c.shards
 	c.Unlock()
 
-	c.closeCh <- struct{}{}
-	return <-c.closeDoneCh
+	// Wait for any enqueue() call that passed the status check just before
+	// Close() flipped it to finish pushing its fragment into its shard's
+	// ingest channel, so that each shard's final drain doesn't miss it.
+	c.inflightWG.Wait()
+
+	for _, shard := range shards {
+		shard.close()
+	}
+
+	return nil
 }
 
-// TODO(rartoul): Kind of gross that this just takes a []byte but more
-// flexible for now.
-func (c *commitlog) Write(b []byte) error {
-	if len(b) == 0 {
-		return errors.New("commit log can not write empty chunk")
+func (c *commitlog) Truncate(token TruncationToken) error {
+	for shardIdx, upTo := range token.upTo {
+		if upTo == nil {
+			// This can occur in the situation where this shard had no existing
+			// commitlogs when the TruncationToken was generated by a call to
+			// WaitForRotation().
+			continue
+		}
+
+		_, err := c.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			tr.ClearRange(fdb.KeyRange{
+				Begin: tuple.Tuple{commitLogKey, c.opts.WriterID, shardIdx},
+				End:   upTo,
+			})
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// WaitForRotation blocks until every shard has durably committed everything
+// enqueued before this call returns, and returns a token marking that point
+// in each shard so it can later be passed to Truncate(). It does this by
+// pushing a zero-length "rotation marker" fragment through each shard's
+// pipeline: since fragments are committed in the order they're received,
+// waiting for the marker's flushOutcome guarantees everything ahead of it in
+// the queue has committed too.
+func (c *commitlog) WaitForRotation() (TruncationToken, error) {
 	c.Lock()
 	if c.status != clStatusOpen {
 		c.Unlock()
-		return errors.New("cannot write into commit log that is not open")
+		return TruncationToken{}, errors.New("cannot wait for commit log rotation if commit log is not open")
 	}
+	shards := c.shards
+	c.Unlock()
 
-	if len(c.currBatch)+len(b) > c.opts.MaxPendingBytes {
-		c.Unlock()
-		return errors.New("commit log queue is full")
+	upTo := make([]tuple.Tuple, len(shards))
+	for i, shard := range shards {
+		lastKey, err := shard.waitForRotation()
+		if err != nil {
+			return TruncationToken{}, err
+		}
+		upTo[i] = lastKey
 	}
 
-	c.currBatch = append(c.currBatch, b...)
-	currFlushOutcome := c.flushOutcome
+	return TruncationToken{upTo: upTo}, nil
+}
+
+// ActiveLogs reports, for every shard, whether it currently has writes sitting
+// in its pipeline that haven't been durably committed yet. A cleanup/replay
+// manager can use this to tell "currently being written" shards (Sealed ==
+// false, keep polling) apart from "nothing outstanding right now" shards
+// (Sealed == true, safe to treat the range as complete for now).
+func (c *commitlog) ActiveLogs() ([]LogInfo, error) {
+	c.Lock()
+	if c.status != clStatusOpen {
+		c.Unlock()
+		return nil, errors.New("cannot list active logs if commit log is not open")
+	}
+	shards := c.shards
 	c.Unlock()
-	return currFlushOutcome.waitForFlush()
+
+	infos := make([]LogInfo, 0, len(shards))
+	for _, shard := range shards {
+		infos = append(infos, LogInfo{
+			WriterID: shard.writerID,
+			ShardIdx: shard.shardIdx,
+			Sealed:   shard.sealed(),
+		})
+	}
+	return infos, nil
 }
 
-func (c *commitlog) Truncate(token truncationToken) error {
-	if token.upTo == nil {
-		// This can occur in the situation where there were no existing commitlogs when
-		// the truncationToken was generated by a call to WaitForRotation().
-		return nil
+// Read returns an Iterator over every record written between fromToken
+// (exclusive; its zero value means "the beginning of each shard") and
+// toToken (exclusive; its zero value means "the current tail of each
+// shard"). Records are yielded shard by shard, in increasing seq order
+// within each shard.
+func (c *commitlog) Read(fromToken, toToken TruncationToken) (Iterator, error) {
+	c.Lock()
+	if c.status != clStatusOpen {
+		c.Unlock()
+		return nil, errors.New("cannot read commit log that is not open")
 	}
+	shards := c.shards
+	c.Unlock()
 
-	_, err := c.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		tr.ClearRange(fdb.KeyRange{Begin: tuple.Tuple{commitLogKey}, End: token.upTo})
-		return nil, nil
-	})
+	ranges := make([]shardReadRange, 0, len(shards))
+	for i, shard := range shards {
+		var begin fdb.KeyConvertible = tuple.Tuple{commitLogKey, shard.writerID, shard.shardIdx}
+		if i < len(fromToken.upTo) && fromToken.upTo[i] != nil {
+			// fromToken is exclusive: start at the smallest key after it.
+			begin = fdb.Key(append(fromToken.upTo[i].Pack(), 0x00))
+		}
 
-	return err
+		end := tuple.Tuple{commitLogKey, shard.writerID, shard.shardIdx, math.MaxInt64}
+		if i < len(toToken.upTo) && toToken.upTo[i] != nil {
+			end = toToken.upTo[i]
+		}
+
+		ranges = append(ranges, shardReadRange{begin: begin, end: end})
+	}
+
+	return newCommitlogIterator(c.db, ranges), nil
 }
 
-func (c *commitlog) WaitForRotation() (truncationToken, error) {
+// RunValueLogGC reclaims FDB keyspace from every shard, Badger-value-log
+// style: it scans each shard's range, asks CommitlogOptions.IsSuperseded
+// about each record, and clear-ranges the oldest contiguous prefix of the
+// range whose superseded-byte ratio is >= discardRatio. MaxRetentionBytes/
+// MaxRetentionAge (if set) bound how far into the tail that prefix is allowed
+// to reach, regardless of what IsSuperseded reports. Lets a deployment run
+// the commitlog standalone, without a bespoke cleanup manager, while still
+// bounding FDB keyspace growth if a downstream flusher stalls.
+func (c *commitlog) RunValueLogGC(discardRatio float64) error {
 	c.Lock()
 	if c.status != clStatusOpen {
 		c.Unlock()
-		return truncationToken{}, errors.New("cannot wait for commit log rotation if commit log is not open")
+		return errors.New("cannot run value log GC if commit log is not open")
 	}
-	currFlushOutcome := c.flushOutcome
+	shards := c.shards
+	isSuperseded := c.opts.IsSuperseded
 	c.Unlock()
 
-	if err := currFlushOutcome.waitForFlush(); err != nil {
-		return truncationToken{}, err
+	if isSuperseded == nil {
+		return errors.New("commitlog: RunValueLogGC requires CommitlogOptions.IsSuperseded to be set")
 	}
 
-	return truncationToken{upTo: currFlushOutcome.lastID}, nil
+	for _, shard := range shards {
+		if err := shard.runGC(isSuperseded, discardRatio, c.opts.MaxRetentionBytes, c.opts.MaxRetentionAge, len(shards)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *commitlog) flush() error {
+// Tail streams every record written from fromToken onward (exclusive; its
+// zero value means "the beginning of each shard"), then keeps the returned
+// channel open and keeps delivering records as new writes commit, until ctx
+// is canceled. Unlike Read(), it never materializes a bounded range and
+// returns: each shard is served by its own goroutine that polls FDB with
+// snapshot reads and exponential backoff once it catches up to the tail, so a
+// slow or stalled consumer only ever backs up the buffered channel, never a
+// writer or commitlog.Lock().
+func (c *commitlog) Tail(ctx context.Context, fromToken TruncationToken) (<-chan Record, error) {
 	c.Lock()
-	currFlushOutcome := c.flushOutcome
-	c.flushOutcome = newFlushOutcome()
-
-	if !(time.Since(c.lastFlushTime) >= c.opts.FlushEvery && len(c.currBatch) > 0) {
+	if c.status != clStatusOpen {
 		c.Unlock()
-		// Notify anyways so that the WaitForRotation() API can function.
-		var lastKey tuple.Tuple
-		if c.lastIdx >= 0 {
-			lastKey = commitlogKeyFromIdx(c.lastIdx)
+		return nil, errors.New("cannot tail commit log that is not open")
+	}
+	shards := c.shards
+	c.Unlock()
+
+	out := make(chan Record, tailChannelBufferSize)
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		var from tuple.Tuple
+		if i < len(fromToken.upTo) {
+			from = fromToken.upTo[i]
 		}
-		currFlushOutcome.notify(lastKey, nil)
-		return nil
+
+		wg.Add(1)
+		go func(shard *commitlogShard, from tuple.Tuple) {
+			defer wg.Done()
+			shard.tail(ctx, from, len(shards), out)
+		}(shard, from)
 	}
 
-	toWrite := c.currBatch
-	c.currBatch, c.prevBatch = c.prevBatch, c.currBatch
-	c.currBatch = c.currBatch[:0]
-	c.Unlock()
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+// writeFragment is a single enqueued write waiting to be folded into a batch.
+type writeFragment struct {
+	payload    []byte
+	outcome    *flushOutcome
+	enqueuedAt time.Time
+}
+
+// pendingBatch is an FDB transaction that has been committed (Commit() called,
+// so it's in flight) but whose future hasn't resolved yet.
+type pendingBatch struct {
+	future     fdb.FutureNil
+	outcomes   []*flushOutcome
+	enqueuedAt []time.Time
+	lastKey    tuple.Tuple
+}
+
+// commitlogShard owns one (writerID, shardIdx) key range and its own ingest
+// queue, batching pipeline, and tail index. Running N shards eliminates FDB
+// contention on a single tail key and lets writers with independent
+// routingKeys make progress in parallel.
+//
+// Each shard runs two goroutines instead of the old mutex-guarded
+// time.Sleep(1ms) poll: a batcher that drains the lock-free ingest channel
+// and cuts FDB transactions, and a completer that waits on those
+// transactions' commit futures in submission order and notifies callers.
+// Because the batcher never blocks on a commit future, it can start cutting
+// the next batch while the previous one is still committing.
+type commitlogShard struct {
+	db       fdb.Database
+	opts     CommitlogOptions
+	writerID string
+	shardIdx int
+
+	pendingCh   chan writeFragment
+	syncQueueCh chan *pendingBatch
+	closeCh     chan struct{}
+	wg          sync.WaitGroup
+
+	// pendingBytes is the lock-free equivalent of the old mutex-guarded
+	// currBatch byte count: an atomic counter incremented by enqueue() and
+	// decremented as the batcher consumes fragments out of pendingCh, used to
+	// enforce MaxPendingBytes without a lock.
+	pendingBytes int64
+
+	// inFlightBatches counts batches that are being cut or have been handed to
+	// syncQueueCh but whose commit future hasn't resolved and been notified
+	// back to callers yet. It's incremented in commitAndEnqueue before
+	// cutBatch releases the batch's pendingBytes, and only
+	// decremented once runCompleter has finished notifying every outcome in
+	// the batch, which is what lets sealed() distinguish "nothing left to
+	// commit" from "len(syncQueueCh) == 0 because runCompleter already
+	// dequeued the last batch but hasn't learned whether it committed yet".
+	inFlightBatches int64
+
+	// lastIdx is only ever touched by the batcher goroutine (single-threaded
+	// by construction), so it needs no synchronization of its own.
+	lastIdx int64
+}
+
+func newCommitlogShard(db fdb.Database, opts CommitlogOptions, shardIdx int) *commitlogShard {
+	return &commitlogShard{
+		db:          db,
+		opts:        opts,
+		writerID:    opts.WriterID,
+		shardIdx:    shardIdx,
+		pendingCh:   make(chan writeFragment, defaultPendingQueueSize),
+		syncQueueCh: make(chan *pendingBatch, defaultSyncQueueSize),
+		closeCh:     make(chan struct{}),
+	}
+}
+
+func (s *commitlogShard) startPipeline() {
+	s.wg.Add(2)
+	go s.runBatcher()
+	go s.runCompleter()
+}
+
+// sealed reports whether every write this shard has accepted so far has
+// already been durably committed to FDB and acknowledged to its caller. It
+// must not go true while a batch is merely sitting in syncQueueCh waiting
+// for its commit future to resolve: ActiveLogs() promises Sealed == true
+// means "safe to stop watching", and runCompleter dequeuing a batch off
+// syncQueueCh happens before it learns whether that batch's commit actually
+// succeeded.
+func (s *commitlogShard) sealed() bool {
+	return atomic.LoadInt64(&s.pendingBytes) == 0 && atomic.LoadInt64(&s.inFlightBatches) == 0
+}
+
+func (s *commitlogShard) enqueue(b []byte) (*flushOutcome, error) {
+	if atomic.AddInt64(&s.pendingBytes, int64(len(b))) > int64(s.opts.MaxPendingBytes) {
+		atomic.AddInt64(&s.pendingBytes, -int64(len(b)))
+		return nil, errors.New("commit log queue is full")
+	}
+
+	outcome := newFlushOutcome()
+	s.pendingCh <- writeFragment{payload: b, outcome: outcome, enqueuedAt: time.Now()}
+	return outcome, nil
+}
+
+// waitForRotation pushes a zero-length marker fragment through the pipeline
+// and waits for it to commit, returning the key it (and everything enqueued
+// ahead of it) ended up under.
+func (s *commitlogShard) waitForRotation() (tuple.Tuple, error) {
+	outcome := newFlushOutcome()
+	s.pendingCh <- writeFragment{outcome: outcome, enqueuedAt: time.Now()}
+	if err := outcome.waitForFlush(); err != nil {
+		return nil, err
+	}
+	return outcome.lastID, nil
+}
+
+func (s *commitlogShard) close() {
+	close(s.closeCh)
+	s.wg.Wait()
+}
+
+// runBatcher drains s.pendingCh and cuts FDB transactions out of it. It never
+// blocks on a commit resolving: it hands the in-flight future off to
+// s.syncQueueCh and immediately starts cutting the next batch.
+func (s *commitlogShard) runBatcher() {
+	defer s.wg.Done()
+	defer close(s.syncQueueCh)
+
+	var carry *writeFragment
+	for {
+		var first writeFragment
+		if carry != nil {
+			first = *carry
+			carry = nil
+		} else {
+			select {
+			case first = <-s.pendingCh:
+			case <-s.closeCh:
+				s.drainOnClose()
+				return
+			}
+		}
+
+		frags, next := s.accumulate(first)
+		carry = next
+		s.commitAndEnqueue(frags)
+	}
+}
+
+// accumulate folds additional fragments onto first, up to IdealBatchSize (or
+// the FDB transaction size limit), either until the queue goes idle for
+// FlushEvery or IdealBatchSize/fdbMaxTxnBytes is reached. If a fragment is
+// pulled off the queue that would push the batch over fdbMaxTxnBytes, it's
+// returned as carry so the next batch starts with it instead of being lost.
+func (s *commitlogShard) accumulate(first writeFragment) (frags []writeFragment, carry *writeFragment) {
+	frags = []writeFragment{first}
+	totalBytes := recordHeaderLen + len(first.payload)
 
-	key, err := c.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		// TODO(rartoul): Need to be smarter about this because don't want to actually
-		// break chunks across writes I.E every call to WriteBatch() should end up
-		// in one key so that each key is a complete unit.
+	for totalBytes < s.opts.IdealBatchSize {
 		var (
-			startIdx = 0
-			key      tuple.Tuple
+			frag writeFragment
+			ok   bool
 		)
-		for startIdx < len(toWrite) {
-			key = c.nextKey()
-			endIdx := startIdx + c.opts.IdealBatchSize
-			if endIdx > len(toWrite) {
-				endIdx = len(toWrite)
+		select {
+		case frag, ok = <-s.pendingCh:
+		default:
+			if s.opts.FlushEvery <= 0 {
+				return frags, nil
+			}
+			select {
+			case frag, ok = <-s.pendingCh:
+			case <-time.After(s.opts.FlushEvery):
+				return frags, nil
 			}
-			tr.Set(key, toWrite[startIdx:endIdx])
-			startIdx = endIdx
+		}
+		if !ok {
+			return frags, nil
 		}
 
-		return key, nil
-	})
-	currFlushOutcome.notify(key.(tuple.Tuple), err)
-	return err
+		n := recordHeaderLen + len(frag.payload)
+		if totalBytes+n > fdbMaxTxnBytes {
+			return frags, &frag
+		}
+		frags = append(frags, frag)
+		totalBytes += n
+	}
+
+	return frags, nil
 }
 
-func (c *commitlog) nextKey() tuple.Tuple {
-	// TODO(rartoul): This should have some kind of host identifier in it.
-	nextKey := commitlogKeyFromIdx(c.lastIdx + 1)
+// drainOnClose folds whatever's left in s.pendingCh into one or more final
+// batches (splitting only if fdbMaxTxnBytes would otherwise be exceeded) so
+// that Close() doesn't silently drop writes that were enqueued but hadn't
+// been batched yet.
+func (s *commitlogShard) drainOnClose() {
+	var frags []writeFragment
+	totalBytes := 0
+	flush := func() {
+		if len(frags) > 0 {
+			s.commitAndEnqueue(frags)
+			frags = nil
+			totalBytes = 0
+		}
+	}
+
+	for {
+		select {
+		case frag, ok := <-s.pendingCh:
+			if !ok {
+				flush()
+				return
+			}
+			n := recordHeaderLen + len(frag.payload)
+			if totalBytes+n > fdbMaxTxnBytes {
+				flush()
+			}
+			frags = append(frags, frag)
+			totalBytes += n
+		default:
+			flush()
+			return
+		}
+	}
+}
+
+func (s *commitlogShard) commitAndEnqueue(frags []writeFragment) {
+	s.opts.Metrics.SetQueueDepth(s.shardIdx, len(s.pendingCh))
+
+	atomic.AddInt64(&s.inFlightBatches, 1)
+	batch, err := s.cutBatch(frags)
+	if err != nil {
+		for _, frag := range frags {
+			atomic.AddInt64(&s.pendingBytes, -int64(len(frag.payload)))
+			frag.outcome.notify(nil, err)
+			s.opts.Metrics.ObserveWriteLatency(time.Since(frag.enqueuedAt))
+		}
+		atomic.AddInt64(&s.inFlightBatches, -1)
+		return
+	}
+
+	s.syncQueueCh <- batch
+}
+
+// cutBatch assigns each fragment in frags its own key/seq (resolving the old
+// TODO about every logical write ending up in its own complete key instead of
+// being arbitrarily sliced across IdealBatchSize boundaries) and commits them
+// all in a single FDB transaction without blocking on the result.
+//
+// A zero-length payload marks a waitForRotation() marker fragment rather than
+// a real write (enqueue() rejects empty writes, so this is unambiguous): it
+// carries no data of its own and must not be written to FDB, or it leaves a
+// permanent phantom empty-payload record behind on every rotation/truncate
+// cycle. It resolves with whatever the last real key in (or before) this
+// batch was instead.
+func (s *commitlogShard) cutBatch(frags []writeFragment) (*pendingBatch, error) {
+	tr, err := s.db.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastKey tuple.Tuple
+	if s.lastIdx >= 0 {
+		lastKey = s.keyFromIdx(s.lastIdx)
+	}
+	var (
+		outcomes   = make([]*flushOutcome, 0, len(frags))
+		enqueuedAt = make([]time.Time, 0, len(frags))
+		totalBytes = 0
+		now        = time.Now()
+	)
+	for _, frag := range frags {
+		outcomes = append(outcomes, frag.outcome)
+		enqueuedAt = append(enqueuedAt, frag.enqueuedAt)
+
+		if len(frag.payload) == 0 {
+			continue
+		}
+
+		key, seq := s.nextKey()
+		record := encodeRecord(seq, now, frag.payload)
+		tr.Set(key, record)
+
+		lastKey = key
+		totalBytes += len(record)
+
+		atomic.AddInt64(&s.pendingBytes, -int64(len(frag.payload)))
+	}
+
+	s.opts.Metrics.ObserveBatchBytes(totalBytes)
+
+	return &pendingBatch{
+		future:     tr.Commit(),
+		outcomes:   outcomes,
+		enqueuedAt: enqueuedAt,
+		lastKey:    lastKey,
+	}, nil
+}
+
+// runCompleter waits on each batch's commit future in submission order (FDB
+// guarantees the futures resolve independently of the order they're waited
+// on, so waiting in submission order is what gives callers FIFO completion)
+// and notifies the outcomes of every write the batch contained.
+func (s *commitlogShard) runCompleter() {
+	defer s.wg.Done()
+
+	for batch := range s.syncQueueCh {
+		s.opts.Metrics.SetSyncQueueDepth(s.shardIdx, len(s.syncQueueCh))
+
+		err := batch.future.Get()
+		now := time.Now()
+		for i, outcome := range batch.outcomes {
+			outcome.notify(batch.lastKey, err)
+			s.opts.Metrics.ObserveWriteLatency(now.Sub(batch.enqueuedAt[i]))
+		}
+		// Only now has this batch's durability been established one way or
+		// the other and every caller waiting on it notified: sealed() must
+		// not see this batch as done any earlier than this.
+		atomic.AddInt64(&s.inFlightBatches, -1)
+	}
+}
+
+func (s *commitlogShard) nextKey() (tuple.Tuple, int64) {
+	nextKey := s.keyFromIdx(s.lastIdx + 1)
 	// Safe to update this optimistically since even if the write ends up failing
 	// its ok to have "gaps".
 	//
 	// Also safe to do this without any locking as this function is always called
-	// in a single-threaded manner.
-	c.lastIdx++
-	return nextKey
+	// in a single-threaded manner (only ever from the batcher goroutine).
+	s.lastIdx++
+	return nextKey, s.lastIdx
 }
 
-func (c *commitlog) getLatestExistingIndex() (int64, bool, error) {
-	key, err := c.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (s *commitlogShard) getLatestExistingIndex() (int64, bool, error) {
+	key, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
 		var (
 			rangeResult = tr.GetRange(fdb.KeyRange{
-				Begin: tuple.Tuple{commitLogKey, 0},
-				End:   tuple.Tuple{commitLogKey, math.MaxInt64}}, fdb.RangeOptions{})
+				Begin: tuple.Tuple{commitLogKey, s.writerID, s.shardIdx, 0},
+				End:   tuple.Tuple{commitLogKey, s.writerID, s.shardIdx, math.MaxInt64}}, fdb.RangeOptions{})
 			iter = rangeResult.Iterator()
 			key  fdb.Key
 		)
@@ -319,13 +1081,367 @@ func (c *commitlog) getLatestExistingIndex() (int64, bool, error) {
 			"malformed commitlog key tuple, expected len: %d, but was: %d, raw: %v",
 			commitLogKeyTupleLength, len(keyTuple), key)
 	}
-	idx, ok := keyTuple[1].(int64)
+	idx, ok := keyTuple[3].(int64)
 	if !ok {
-		return -1, false, errors.New("malformed commitlog key tuple, expected second value to be of type int64")
+		return -1, false, errors.New("malformed commitlog key tuple, expected fourth value to be of type int64")
 	}
 	return idx, true, nil
 }
 
-func commitlogKeyFromIdx(idx int64) tuple.Tuple {
-	return tuple.Tuple{commitLogKey, idx + 1}
+func (s *commitlogShard) keyFromIdx(idx int64) tuple.Tuple {
+	return tuple.Tuple{commitLogKey, s.writerID, s.shardIdx, idx + 1}
+}
+
+// runGC scans this shard's entire key range and clear-ranges the oldest
+// contiguous prefix whose superseded-byte ratio is >= discardRatio, never
+// reaching into the trailing maxRetentionBytes/maxRetentionAge of the range.
+func (s *commitlogShard) runGC(
+	isSuperseded SupersededFunc,
+	discardRatio float64,
+	maxRetentionBytes int64,
+	maxRetentionAge time.Duration,
+	numShards int,
+) error {
+	entries, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return tr.GetRange(fdb.KeyRange{
+			Begin: tuple.Tuple{commitLogKey, s.writerID, s.shardIdx},
+			End:   tuple.Tuple{commitLogKey, s.writerID, s.shardIdx, math.MaxInt64},
+		}, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if err != nil {
+		return err
+	}
+
+	kvs := entries.([]fdb.KeyValue)
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	protectedFrom, err := s.gcProtectedFrom(kvs, maxRetentionBytes, maxRetentionAge)
+	if err != nil {
+		return err
+	}
+
+	var (
+		scannedBytes    int64
+		supersededBytes int64
+		clearThrough    = -1
+	)
+	for i := 0; i < protectedFrom; i++ {
+		kv := kvs[i]
+		scannedBytes += int64(len(kv.Value))
+
+		keyTuple, err := tuple.Unpack(kv.Key)
+		if err != nil {
+			return err
+		}
+		upTo := make([]tuple.Tuple, numShards)
+		upTo[s.shardIdx] = keyTuple
+		if isSuperseded(TruncationToken{upTo: upTo}) {
+			supersededBytes += int64(len(kv.Value))
+		}
+		if float64(supersededBytes)/float64(scannedBytes) >= discardRatio {
+			clearThrough = i
+		}
+	}
+	if clearThrough < 0 {
+		return nil
+	}
+
+	// ClearRange's End is exclusive, so appending a single 0x00 byte to the
+	// last key we want cleared gives the smallest key that sorts after it,
+	// without needing to know what the next real key happens to be.
+	end := append(append(fdb.Key{}, []byte(kvs[clearThrough].Key)...), 0x00)
+
+	_, err = s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(fdb.KeyRange{
+			Begin: tuple.Tuple{commitLogKey, s.writerID, s.shardIdx},
+			End:   end,
+		})
+		return nil, nil
+	})
+	return err
+}
+
+// gcProtectedFrom returns the index of the first entry that runGC must never
+// reclaim, computed independently for the bytes- and age-based retention
+// floors and then taking whichever one protects more of the tail.
+func (s *commitlogShard) gcProtectedFrom(kvs []fdb.KeyValue, maxRetentionBytes int64, maxRetentionAge time.Duration) (int, error) {
+	protectedFrom := len(kvs)
+
+	if maxRetentionBytes > 0 {
+		var bytes int64
+		i := len(kvs)
+		for i > 0 && bytes < maxRetentionBytes {
+			i--
+			bytes += int64(len(kvs[i].Value))
+		}
+		if i < protectedFrom {
+			protectedFrom = i
+		}
+	}
+
+	if maxRetentionAge > 0 {
+		now := time.Now()
+		i := len(kvs)
+		for i > 0 {
+			_, timestamp, _, err := decodeRecord(kvs[i-1].Value)
+			if err != nil && err != errCorruptRecord {
+				return 0, err
+			}
+			if now.Sub(timestamp) > maxRetentionAge {
+				break
+			}
+			i--
+		}
+		if i < protectedFrom {
+			protectedFrom = i
+		}
+	}
+
+	return protectedFrom, nil
+}
+
+// tail feeds out with every record committed to this shard from (exclusive)
+// until ctx is canceled, at which point it returns. It never takes s's
+// pendingCh/syncQueueCh or commitlog.Lock(): it only ever reads the shard's
+// key range with FDB snapshot transactions, so it cannot add latency to the
+// write path regardless of how far behind it falls.
+// numShards is the total number of shards this commitlog was opened with
+// (i.e. len(c.shards)), needed so the Token on each emitted Record carries
+// this shard's key at its own position rather than always at index 0 —
+// otherwise feeding that token back in as a fromToken would silently
+// corrupt a subsequent Read()/Tail() call for any NumShards > 1 config.
+func (s *commitlogShard) tail(ctx context.Context, from tuple.Tuple, numShards int, out chan<- Record) {
+	var begin fdb.KeyConvertible = tuple.Tuple{commitLogKey, s.writerID, s.shardIdx}
+	if from != nil {
+		// from is exclusive: start at the smallest key after it.
+		begin = fdb.Key(append(from.Pack(), 0x00))
+	}
+	end := tuple.Tuple{commitLogKey, s.writerID, s.shardIdx, math.MaxInt64}
+
+	backoff := tailMinPollInterval
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		kvs, err := s.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			return tr.Snapshot().GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{}).GetSliceWithError()
+		})
+		if err != nil {
+			log.Printf("commitlog: tail poll of shard %d failed, retrying: %v", s.shardIdx, err)
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		rows := kvs.([]fdb.KeyValue)
+		if len(rows) == 0 {
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		emitted := false
+		for i, kv := range rows {
+			seq, timestamp, payload, decodeErr := decodeRecord(kv.Value)
+			if decodeErr == errCorruptRecord {
+				if i == len(rows)-1 {
+					// Same convention as commitlogIterator: the last row of
+					// a poll is allowed to be torn, since it may simply not
+					// be durable yet. Stop here and re-poll from begin.
+					break
+				}
+				log.Printf("commitlog: tail skipping corrupt record at key %v", kv.Key)
+				continue
+			} else if decodeErr != nil {
+				log.Printf("commitlog: tail skipping undecodable record at key %v: %v", kv.Key, decodeErr)
+				continue
+			}
+
+			keyTuple, unpackErr := tuple.Unpack(kv.Key)
+			if unpackErr != nil {
+				log.Printf("commitlog: tail failed to unpack key %v: %v", kv.Key, unpackErr)
+				continue
+			}
+
+			upTo := make([]tuple.Tuple, numShards)
+			upTo[s.shardIdx] = keyTuple
+
+			rec := Record{
+				Token:     TruncationToken{upTo: upTo},
+				Seq:       seq,
+				Timestamp: timestamp,
+				Payload:   payload,
+			}
+
+			select {
+			case out <- rec:
+			case <-ctx.Done():
+				return
+			}
+			emitted = true
+
+			// ClearRange-style exclusive lower bound: resume strictly after
+			// the last key this goroutine has handed to the consumer.
+			begin = append(append(fdb.Key{}, []byte(kv.Key)...), 0x00)
+		}
+
+		if !emitted {
+			// Only torn or undecodable rows remain: still caught up.
+			if !sleepOrDone(ctx, backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		backoff = tailMinPollInterval
+	}
+}
+
+// sleepOrDone waits for d or until ctx is canceled, reporting whether it
+// finished the full wait (false means the caller should stop tailing).
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// nextBackoff doubles d, capped at tailMaxPollInterval, for the poll loop in
+// commitlogShard.tail to back off under when a shard is idle.
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > tailMaxPollInterval {
+		return tailMaxPollInterval
+	}
+	return d
+}
+
+// shardReadRange is the [begin, end) FDB key range a commitlogIterator reads
+// for a single shard.
+type shardReadRange struct {
+	begin fdb.KeyConvertible
+	end   tuple.Tuple
+}
+
+// commitlogIterator implements Iterator by reading one shard's range at a
+// time into memory and decoding its records in order.
+type commitlogIterator struct {
+	db       fdb.Database
+	ranges   []shardReadRange
+	rangeIdx int
+
+	kvs   []fdb.KeyValue
+	kvIdx int
+
+	curr Record
+	err  error
+	done bool
+}
+
+func newCommitlogIterator(db fdb.Database, ranges []shardReadRange) *commitlogIterator {
+	return &commitlogIterator{db: db, ranges: ranges}
+}
+
+func (it *commitlogIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	for {
+		if it.kvIdx >= len(it.kvs) {
+			if !it.loadNextRange() {
+				return false
+			}
+		}
+
+		kv := it.kvs[it.kvIdx]
+		it.kvIdx++
+		isTailOfShard := it.kvIdx == len(it.kvs)
+
+		seq, timestamp, payload, err := decodeRecord(kv.Value)
+		if err == errCorruptRecord {
+			if isTailOfShard {
+				// The last record of a shard's range is allowed to fail its
+				// checksum: it may simply be a write that hasn't been
+				// durably committed yet from the replayer's point of view.
+				// Treat it as "not there yet" rather than corruption.
+				it.done = true
+				return false
+			}
+			it.err = fmt.Errorf("commitlog: corrupt record at key %v: %w", kv.Key, err)
+			return false
+		} else if err != nil {
+			it.err = err
+			return false
+		}
+
+		keyTuple, unpackErr := tuple.Unpack(kv.Key)
+		if unpackErr != nil {
+			it.err = unpackErr
+			return false
+		}
+
+		// it.rangeIdx was already advanced past this shard's range by
+		// loadNextRange(), and ranges is built 1:1 with c.shards in the same
+		// order, so rangeIdx-1 is this record's position in c.shards. The
+		// token must carry that position (not always index 0), or feeding it
+		// back into Read() as fromToken resumes the wrong shard.
+		upTo := make([]tuple.Tuple, len(it.ranges))
+		upTo[it.rangeIdx-1] = keyTuple
+
+		it.curr = Record{
+			Token:     TruncationToken{upTo: upTo},
+			Seq:       seq,
+			Timestamp: timestamp,
+			Payload:   payload,
+		}
+		return true
+	}
+}
+
+// loadNextRange materializes the next non-empty shard range into it.kvs. It
+// returns false once there are no more ranges left to try.
+func (it *commitlogIterator) loadNextRange() bool {
+	for it.rangeIdx < len(it.ranges) {
+		r := it.ranges[it.rangeIdx]
+		it.rangeIdx++
+
+		kvs, err := it.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			return tr.GetRange(fdb.KeyRange{Begin: r.begin, End: r.end}, fdb.RangeOptions{}).GetSliceWithError()
+		})
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.kvs = kvs.([]fdb.KeyValue)
+		it.kvIdx = 0
+		if len(it.kvs) > 0 {
+			return true
+		}
+	}
+
+	it.done = true
+	return false
+}
+
+func (it *commitlogIterator) Record() Record {
+	return it.curr
+}
+
+func (it *commitlogIterator) Err() error {
+	return it.err
+}
+
+func (it *commitlogIterator) Close() {
+	it.kvs = nil
 }
@@ -0,0 +1,69 @@
+package rawblock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRecordRoundTrip(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	payload := []byte("hello world")
+
+	raw := encodeRecord(42, now, payload)
+
+	seq, timestamp, decoded, err := decodeRecord(raw)
+	if err != nil {
+		t.Fatalf("decodeRecord returned unexpected error: %v", err)
+	}
+	if seq != 42 {
+		t.Fatalf("seq = %d, want 42", seq)
+	}
+	if !timestamp.Equal(now) {
+		t.Fatalf("timestamp = %v, want %v", timestamp, now)
+	}
+	if string(decoded) != string(payload) {
+		t.Fatalf("payload = %q, want %q", decoded, payload)
+	}
+}
+
+// TestDecodeRecordDetectsCorruption covers the CRC-torn-write detection that
+// commitlogIterator.Next() and commitlogShard.tail() rely on to distinguish a
+// torn (not yet durable) tail record from a real mid-range corruption: a
+// payload that's been tampered with after encoding must come back as
+// errCorruptRecord, alongside the best-effort seq/timestamp it could still
+// parse, rather than silently being accepted.
+func TestDecodeRecordDetectsCorruption(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	raw := encodeRecord(7, now, []byte("payload"))
+
+	// Flip a bit in the payload without touching the header, simulating a
+	// write whose key is visible in a range scan but whose value hasn't
+	// finished being durably written.
+	raw[len(raw)-1] ^= 0xFF
+
+	seq, timestamp, _, err := decodeRecord(raw)
+	if err != errCorruptRecord {
+		t.Fatalf("err = %v, want errCorruptRecord", err)
+	}
+	if seq != 7 {
+		t.Fatalf("seq = %d, want 7 even on checksum failure", seq)
+	}
+	if !timestamp.Equal(now) {
+		t.Fatalf("timestamp = %v, want %v even on checksum failure", timestamp, now)
+	}
+}
+
+func TestDecodeRecordRejectsBadMagic(t *testing.T) {
+	raw := encodeRecord(1, time.Now(), []byte("x"))
+	raw[0] = 'X'
+
+	if _, _, _, err := decodeRecord(raw); err == nil {
+		t.Fatal("expected an error for a record with corrupted magic bytes")
+	}
+}
+
+func TestDecodeRecordRejectsTooShort(t *testing.T) {
+	if _, _, _, err := decodeRecord([]byte("short")); err == nil {
+		t.Fatal("expected an error for a record shorter than the header")
+	}
+}
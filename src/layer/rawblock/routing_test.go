@@ -0,0 +1,73 @@
+package rawblock
+
+import "testing"
+
+func newTestCommitlog(numShards int) *commitlog {
+	shards := make([]*commitlogShard, numShards)
+	for i := range shards {
+		shards[i] = &commitlogShard{shardIdx: i}
+	}
+	return &commitlog{shards: shards}
+}
+
+func TestShardForSingleShardAlwaysReturnsIt(t *testing.T) {
+	c := newTestCommitlog(1)
+
+	for _, key := range [][]byte{nil, []byte("a"), []byte("b")} {
+		if got := c.shardFor(key); got != c.shards[0] {
+			t.Fatalf("shardFor(%q) = %v, want the only shard", key, got)
+		}
+	}
+}
+
+// TestShardForRoutedIsDeterministic covers the "writes that share a
+// routingKey land in the same shard" half of WriteRouted's documented
+// contract: the same key must always hash to the same shard.
+func TestShardForRoutedIsDeterministic(t *testing.T) {
+	c := newTestCommitlog(4)
+	key := []byte("series-42")
+
+	want := c.shardFor(key)
+	for i := 0; i < 10; i++ {
+		if got := c.shardFor(key); got != want {
+			t.Fatalf("shardFor(%q) = %v on call %d, want %v (same every time)", key, got, i, want)
+		}
+	}
+}
+
+// TestShardForRoutedSpreadsAcrossShards covers the other half: different
+// routing keys should generally land on different shards, not all pile onto
+// one because of a hashing bug (e.g. always taking the hash mod 1).
+func TestShardForRoutedSpreadsAcrossShards(t *testing.T) {
+	c := newTestCommitlog(4)
+
+	seen := make(map[*commitlogShard]bool)
+	for i := 0; i < 100; i++ {
+		key := []byte{byte(i), byte(i >> 8)}
+		seen[c.shardFor(key)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("100 distinct routing keys landed on only %d shard(s), want spread across multiple", len(seen))
+	}
+}
+
+// TestShardForRoundRobinCyclesEvenly covers WriteRouted's "writes with a nil
+// routingKey are spread round-robin across shards" contract.
+func TestShardForRoundRobinCyclesEvenly(t *testing.T) {
+	c := newTestCommitlog(3)
+
+	counts := make(map[*commitlogShard]int)
+	const rounds = 5
+	for i := 0; i < rounds*len(c.shards); i++ {
+		counts[c.shardFor(nil)]++
+	}
+
+	if len(counts) != len(c.shards) {
+		t.Fatalf("round-robin touched %d distinct shards, want all %d", len(counts), len(c.shards))
+	}
+	for shard, n := range counts {
+		if n != rounds {
+			t.Fatalf("shard %d got %d writes, want exactly %d from an even round-robin", shard.shardIdx, n, rounds)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package rawblock
+
+import "testing"
+
+// TestSealedRequiresBothPendingBytesAndInFlightBatchesZero guards the
+// sealed()/ActiveLogs() regression fixed alongside this test: a shard must
+// not report Sealed == true while a batch it already cut is still sitting in
+// syncQueueCh waiting on its commit future, since a bootstrap/replay manager
+// trusts Sealed == true to mean the shard's writes are durably committed.
+func TestSealedRequiresBothPendingBytesAndInFlightBatchesZero(t *testing.T) {
+	cases := []struct {
+		name            string
+		pendingBytes    int64
+		inFlightBatches int64
+		want            bool
+	}{
+		{"idle", 0, 0, true},
+		{"unbatched writes still queued", 5, 0, false},
+		{"batch cut but commit future not yet resolved", 0, 1, false},
+		{"both outstanding", 5, 1, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &commitlogShard{pendingBytes: tc.pendingBytes, inFlightBatches: tc.inFlightBatches}
+			if got := s.sealed(); got != tc.want {
+				t.Fatalf("sealed() = %v, want %v (pendingBytes=%d, inFlightBatches=%d)",
+					got, tc.want, tc.pendingBytes, tc.inFlightBatches)
+			}
+		})
+	}
+}
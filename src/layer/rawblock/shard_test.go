@@ -0,0 +1,83 @@
+package rawblock
+
+import (
+	"testing"
+)
+
+func newTestShard(idealBatchSize int) *commitlogShard {
+	return &commitlogShard{
+		opts:      CommitlogOptions{IdealBatchSize: idealBatchSize},
+		pendingCh: make(chan writeFragment, defaultPendingQueueSize),
+	}
+}
+
+// TestAccumulateFoldsQueuedFragmentsIntoOneBatch covers the batcher's core
+// group-commit behavior: fragments already sitting in pendingCh get folded
+// into the same batch as first, rather than each cutting its own
+// transaction, as long as IdealBatchSize hasn't been reached yet.
+func TestAccumulateFoldsQueuedFragmentsIntoOneBatch(t *testing.T) {
+	s := newTestShard(1 << 20) // large enough that nothing here trips the size limit
+
+	second := writeFragment{payload: []byte("second")}
+	third := writeFragment{payload: []byte("third")}
+	s.pendingCh <- second
+	s.pendingCh <- third
+
+	first := writeFragment{payload: []byte("first")}
+	frags, carry := s.accumulate(first)
+
+	if carry != nil {
+		t.Fatalf("carry = %+v, want nil", carry)
+	}
+	if len(frags) != 3 {
+		t.Fatalf("len(frags) = %d, want 3", len(frags))
+	}
+	if string(frags[0].payload) != "first" || string(frags[1].payload) != "second" || string(frags[2].payload) != "third" {
+		t.Fatalf("frags out of order: %+v", frags)
+	}
+}
+
+// TestAccumulateStopsAtIdealBatchSize covers the other half of the batcher's
+// group-commit contract: once the running total reaches IdealBatchSize, it
+// must stop folding in more fragments even if more are already queued, so a
+// batch can't grow without bound.
+func TestAccumulateStopsAtIdealBatchSize(t *testing.T) {
+	first := writeFragment{payload: make([]byte, 10)}
+	s := newTestShard(recordHeaderLen + len(first.payload)) // first alone already meets the target
+
+	s.pendingCh <- writeFragment{payload: make([]byte, 10)}
+
+	frags, carry := s.accumulate(first)
+
+	if carry != nil {
+		t.Fatalf("carry = %+v, want nil", carry)
+	}
+	if len(frags) != 1 {
+		t.Fatalf("len(frags) = %d, want 1 (should stop as soon as IdealBatchSize is reached)", len(frags))
+	}
+}
+
+// TestAccumulateCarriesOversizedFragmentToNextBatch covers the fdbMaxTxnBytes
+// guard: a fragment that would push the batch over FDB's transaction size
+// limit must be handed back as carry (so the next batch starts with it)
+// instead of being silently dropped or forced into a transaction FDB would
+// reject.
+func TestAccumulateCarriesOversizedFragmentToNextBatch(t *testing.T) {
+	s := newTestShard(1 << 30) // never hit by size alone; this test is about fdbMaxTxnBytes
+
+	oversized := writeFragment{payload: make([]byte, fdbMaxTxnBytes)}
+	s.pendingCh <- oversized
+
+	first := writeFragment{payload: []byte("small")}
+	frags, carry := s.accumulate(first)
+
+	if len(frags) != 1 {
+		t.Fatalf("len(frags) = %d, want 1 (oversized fragment should not be folded in)", len(frags))
+	}
+	if carry == nil {
+		t.Fatal("carry = nil, want the oversized fragment carried to the next batch")
+	}
+	if len(carry.payload) != fdbMaxTxnBytes {
+		t.Fatalf("carry payload len = %d, want %d", len(carry.payload), fdbMaxTxnBytes)
+	}
+}
@@ -0,0 +1,53 @@
+package rawblock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestNextBackoffDoublesAndCaps covers the backoff commitlogShard.tail() uses
+// once it's caught up to a shard's tail: it must double each time, capped at
+// tailMaxPollInterval so a persistently idle shard doesn't poll less and less
+// often without bound.
+func TestNextBackoffDoublesAndCaps(t *testing.T) {
+	d := tailMinPollInterval
+	for d < tailMaxPollInterval {
+		next := nextBackoff(d)
+		if next != d*2 && next != tailMaxPollInterval {
+			t.Fatalf("nextBackoff(%v) = %v, want either %v or the cap %v", d, next, d*2, tailMaxPollInterval)
+		}
+		d = next
+	}
+
+	if got := nextBackoff(tailMaxPollInterval); got != tailMaxPollInterval {
+		t.Fatalf("nextBackoff(tailMaxPollInterval) = %v, want %v (must not exceed the cap)", got, tailMaxPollInterval)
+	}
+}
+
+// TestSleepOrDoneReturnsFalseOnCancel covers the other half of tail()'s
+// backoff loop: a canceled ctx must make sleepOrDone return immediately with
+// false (telling the caller to stop tailing) instead of waiting out the full
+// duration.
+func TestSleepOrDoneReturnsFalseOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan bool, 1)
+	go func() { done <- sleepOrDone(ctx, time.Hour) }()
+
+	select {
+	case got := <-done:
+		if got {
+			t.Fatal("sleepOrDone() = true, want false for an already-canceled context")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("sleepOrDone() did not return promptly for a canceled context")
+	}
+}
+
+func TestSleepOrDoneReturnsTrueAfterDuration(t *testing.T) {
+	if !sleepOrDone(context.Background(), time.Millisecond) {
+		t.Fatal("sleepOrDone() = false, want true once the duration elapses with no cancellation")
+	}
+}
@@ -0,0 +1,58 @@
+package rawblock
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestWriteHandleSyncWaitBlocksUntilNotified covers the Pebble-style
+// ApplyNoSyncWait/SyncWait split: SyncWait() must block until notify() is
+// called and then return whatever error was passed to it.
+func TestWriteHandleSyncWaitBlocksUntilNotified(t *testing.T) {
+	outcome := newFlushOutcome()
+	handle := &WriteHandle{outcome: outcome}
+
+	done := make(chan error, 1)
+	go func() { done <- handle.SyncWait() }()
+
+	select {
+	case err := <-done:
+		t.Fatalf("SyncWait() returned %v before notify() was called", err)
+	default:
+	}
+
+	wantErr := errors.New("commit failed")
+	outcome.notify(nil, wantErr)
+
+	if err := <-done; err != wantErr {
+		t.Fatalf("SyncWait() = %v, want %v", err, wantErr)
+	}
+}
+
+func TestWriteHandleSyncWaitReturnsNilOnSuccessfulNotify(t *testing.T) {
+	outcome := newFlushOutcome()
+	handle := &WriteHandle{outcome: outcome}
+
+	outcome.notify(nil, nil)
+
+	if err := handle.SyncWait(); err != nil {
+		t.Fatalf("SyncWait() = %v, want nil", err)
+	}
+}
+
+// TestWriteHandleSyncWaitClearsOutcomeBeforePooling covers that SyncWait()
+// drops its reference to the outcome before returning the handle to
+// writeHandlePool, so a handle pulled back out by WriteAsync()/
+// WriteAsyncRouted() never starts out pointing at a stale outcome.
+func TestWriteHandleSyncWaitClearsOutcomeBeforePooling(t *testing.T) {
+	outcome := newFlushOutcome()
+	outcome.notify(nil, nil)
+
+	handle := &WriteHandle{outcome: outcome}
+	if err := handle.SyncWait(); err != nil {
+		t.Fatalf("SyncWait() = %v, want nil", err)
+	}
+	if handle.outcome != nil {
+		t.Fatal("SyncWait() must clear outcome before returning the handle to the pool")
+	}
+}